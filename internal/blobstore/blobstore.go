@@ -0,0 +1,74 @@
+// Package blobstore backs go-git's storage.Storer with a remote object store
+// (S3 or GCS) instead of local disk, so a fetched SHA's loose/packed objects
+// and refs can be shared across CI workers without re-fetching from the
+// upstream git remote.
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Client is the minimal blob operations a Filesystem needs from an object
+// store. Implementations: s3Client, gcsClient.
+type Client interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Scheme identifies which object store a --directory URL points at.
+type Scheme string
+
+const (
+	SchemeS3  Scheme = "s3"
+	SchemeGCS Scheme = "gs"
+)
+
+// ParseURL splits a "s3://bucket/prefix" or "gs://bucket/prefix" directory
+// into its scheme, bucket and key prefix.
+func ParseURL(rawURL string) (scheme Scheme, bucket, prefix string, err error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid object store URL: %w", err)
+	}
+
+	switch parsed.Scheme {
+	case string(SchemeS3):
+		scheme = SchemeS3
+	case string(SchemeGCS):
+		scheme = SchemeGCS
+	default:
+		return "", "", "", fmt.Errorf("%q is not a supported object store scheme (want s3:// or gs://)", parsed.Scheme)
+	}
+
+	if parsed.Host == "" {
+		return "", "", "", fmt.Errorf("%q is missing a bucket name", rawURL)
+	}
+
+	return scheme, parsed.Host, strings.TrimPrefix(parsed.Path, "/"), nil
+}
+
+// NewClient builds the Client for the given directory URL, picking up
+// credentials from the standard env/SDK chains for that provider.
+func NewClient(ctx context.Context, rawURL string) (client Client, prefix string, err error) {
+	scheme, bucket, prefix, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch scheme {
+	case SchemeS3:
+		client, err = newS3Client(ctx, bucket)
+	case SchemeGCS:
+		client, err = newGCSClient(ctx, bucket)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return client, prefix, nil
+}
@@ -0,0 +1,314 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// Filesystem is a billy.Filesystem that lazily hydrates files from a remote
+// object store on read and flushes them back on write, so go-git's loose
+// objects, packs and refs end up persisted in S3/GCS rather than on local
+// disk. Reads/writes of any single file are buffered through an in-memory
+// cache; writes reach the network only on Close() of a file opened for
+// writing, on Rename() and on Remove().
+//
+// Discovery (ReadDir/Stat) also consults the object store, not just the
+// local cache: this is what lets a second process pointed at the same
+// directory see objects a first process already pushed there, instead of
+// finding an empty cache and re-fetching from the upstream git remote.
+type Filesystem struct {
+	billy.Filesystem // delegate: in-memory cache, also satisfies Chroot/Dir/Symlink
+
+	client Client
+	prefix string
+
+	mu      sync.Mutex
+	listed  map[string]bool // directories already listed from the remote into the cache
+	pending map[string]bool // filenames materialized as placeholders by a directory listing, not yet content-hydrated
+}
+
+// New wraps client so that paths are stored under prefix, using an in-memory
+// filesystem as the local read/write cache.
+func New(client Client, prefix string) *Filesystem {
+	return &Filesystem{
+		Filesystem: memfs.New(),
+		client:     client,
+		prefix:     prefix,
+		listed:     map[string]bool{},
+		pending:    map[string]bool{},
+	}
+}
+
+func (fs *Filesystem) key(filename string) string {
+	return path.Join(fs.prefix, filename)
+}
+
+// hydrate ensures filename's real content (not just a placeholder) is
+// present in the local cache before it's opened for reading.
+func (fs *Filesystem) hydrate(filename string) error {
+	fs.mu.Lock()
+	pending := fs.pending[filename]
+	fs.mu.Unlock()
+
+	if !pending {
+		if _, err := fs.Filesystem.Stat(filename); err == nil {
+			return nil
+		}
+	}
+
+	data, err := fs.client.Get(context.Background(), fs.key(filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := fs.Filesystem.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := fs.Filesystem.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	delete(fs.pending, filename)
+	fs.mu.Unlock()
+
+	return nil
+}
+
+// hydrateDir lists dirname's remote contents (once) into the local cache:
+// a real directory for each further subdirectory, and a zero-byte pending
+// placeholder for each leaf file, so ReadDir/Stat can see names that no
+// local write has created yet. hydrate fills in a placeholder's real
+// content lazily, when it's actually opened.
+func (fs *Filesystem) hydrateDir(dirname string) error {
+	clean := path.Clean("/" + dirname)
+
+	fs.mu.Lock()
+	already := fs.listed[clean]
+	fs.mu.Unlock()
+	if already {
+		return nil
+	}
+
+	prefix := fs.key(dirname)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	keys, err := fs.client.List(context.Background(), prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		rel := strings.TrimPrefix(key, prefix)
+		if rel == "" {
+			continue
+		}
+
+		segment, isDir := rel, false
+		if idx := strings.IndexByte(rel, '/'); idx >= 0 {
+			segment, isDir = rel[:idx], true
+		}
+
+		filename := path.Join(dirname, segment)
+		if _, err := fs.Filesystem.Stat(filename); err == nil {
+			continue
+		}
+
+		if isDir {
+			if err := fs.Filesystem.MkdirAll(filename, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := fs.touchPlaceholder(filename); err != nil {
+			return err
+		}
+	}
+
+	fs.mu.Lock()
+	fs.listed[clean] = true
+	fs.mu.Unlock()
+
+	return nil
+}
+
+func (fs *Filesystem) touchPlaceholder(filename string) error {
+	f, err := fs.Filesystem.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	fs.pending[filename] = true
+	fs.mu.Unlock()
+
+	return nil
+}
+
+func (fs *Filesystem) ReadDir(dirname string) ([]os.FileInfo, error) {
+	if err := fs.hydrateDir(dirname); err != nil {
+		return nil, err
+	}
+
+	return fs.Filesystem.ReadDir(dirname)
+}
+
+func (fs *Filesystem) Stat(filename string) (os.FileInfo, error) {
+	if info, err := fs.Filesystem.Stat(filename); err == nil {
+		return info, nil
+	}
+
+	if err := fs.hydrateDir(path.Dir(filename)); err != nil {
+		return nil, err
+	}
+
+	return fs.Filesystem.Stat(filename)
+}
+
+func (fs *Filesystem) Open(filename string) (billy.File, error) {
+	if err := fs.hydrate(filename); err != nil {
+		return nil, err
+	}
+
+	// Opened read-only: nothing to flush back on Close.
+	return fs.Filesystem.Open(filename)
+}
+
+func (fs *Filesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if err := fs.hydrate(filename); err != nil {
+		return nil, err
+	}
+
+	f, err := fs.Filesystem.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC) == 0 {
+		return f, nil
+	}
+
+	return &syncedFile{File: f, fs: fs, filename: filename}, nil
+}
+
+func (fs *Filesystem) Create(filename string) (billy.File, error) {
+	f, err := fs.Filesystem.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.mu.Lock()
+	delete(fs.pending, filename)
+	fs.mu.Unlock()
+
+	return &syncedFile{File: f, fs: fs, filename: filename}, nil
+}
+
+func (fs *Filesystem) Remove(filename string) error {
+	if err := fs.Filesystem.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	fs.mu.Lock()
+	delete(fs.pending, filename)
+	fs.mu.Unlock()
+
+	return fs.client.Delete(context.Background(), fs.key(filename))
+}
+
+// TempFile delegates to the in-memory cache untouched: go-git's dotgit
+// storage writes packs and loose objects by writing to a TempFile and then
+// Rename-ing it to its final path, so the sync to the object store happens
+// in Rename below, once the file has the name it'll actually be read back
+// under.
+func (fs *Filesystem) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.Filesystem.TempFile(dir, prefix)
+}
+
+func (fs *Filesystem) Rename(oldpath, newpath string) error {
+	if err := fs.Filesystem.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+
+	data, err := util.ReadFile(fs.Filesystem, newpath)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.client.Put(context.Background(), fs.key(newpath), data); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	delete(fs.pending, oldpath)
+	delete(fs.pending, newpath)
+	fs.mu.Unlock()
+
+	if oldKey := fs.key(oldpath); oldKey != fs.key(newpath) {
+		if err := fs.client.Delete(context.Background(), oldKey); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *Filesystem) Chroot(p string) (billy.Filesystem, error) {
+	sub, err := fs.Filesystem.Chroot(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filesystem{
+		Filesystem: sub,
+		client:     fs.client,
+		prefix:     fs.key(p),
+		listed:     map[string]bool{},
+		pending:    map[string]bool{},
+	}, nil
+}
+
+// syncedFile flushes its contents up to the object store whenever it is
+// closed after having been opened for writing.
+type syncedFile struct {
+	billy.File
+	fs       *Filesystem
+	filename string
+}
+
+func (f *syncedFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+
+	data, err := util.ReadFile(f.fs.Filesystem, f.filename)
+	if err != nil {
+		return err
+	}
+
+	return f.fs.client.Put(context.Background(), f.fs.key(f.filename), data)
+}
@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// SubmoduleStorer builds the storage.Storer for a submodule's own git
+// objects/refs, given the sub-filesystem rooted at the submodule's path,
+// so that recursive submodule fetches inherit the parent's storage mode
+// instead of always landing in memory.
+type SubmoduleStorer func(sub billy.Filesystem) (storage.Storer, error)
+
+// Fetch performs a shallow fetch of sha from repoURL into wt (backed by
+// storer) and checks it out. When recursive is true, it then parses
+// .gitmodules out of the checked-out tree and recurses into each submodule,
+// fetching the exact SHA recorded in the parent tree into a sub-filesystem
+// rooted at the submodule's path, with its storer built by newSubmoduleStorer.
+func Fetch(ctx context.Context, repoURL, sha string, auth transport.AuthMethod, storer storage.Storer, wt billy.Filesystem, recursive bool, newSubmoduleStorer SubmoduleStorer) error {
+	repo, err := git.Init(storer, wt)
+	if err != nil {
+		return fmt.Errorf("initializing repo: %w", err)
+	}
+
+	_, err = repo.CreateRemote(&gitconfig.RemoteConfig{
+		Name: "origin",
+		URLs: []string{resolveRepoURL(repoURL)},
+	})
+	if err != nil {
+		return fmt.Errorf("creating remote: %w", err)
+	}
+
+	hash := plumbing.NewHash(sha)
+
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		Auth:       auth,
+		Depth:      1,
+		RefSpecs: []gitconfig.RefSpec{
+			gitconfig.RefSpec(fmt.Sprintf("%s:%s", sha, sha)),
+		},
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("fetching %s: %w", sha, err)
+	}
+
+	commitObj, err := repo.CommitObject(hash)
+	if err != nil {
+		return fmt.Errorf("loading commit %s: %w", sha, err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: hash}); err != nil {
+		return fmt.Errorf("checking out %s: %w", sha, err)
+	}
+
+	if !recursive {
+		return nil
+	}
+
+	tree, err := commitObj.Tree()
+	if err != nil {
+		return fmt.Errorf("loading tree of %s: %w", sha, err)
+	}
+
+	return fetchSubmodules(ctx, repoURL, auth, wt, tree, newSubmoduleStorer)
+}
+
+func fetchSubmodules(ctx context.Context, parentRepoURL string, auth transport.AuthMethod, wt billy.Filesystem, tree *object.Tree, newSubmoduleStorer SubmoduleStorer) error {
+	data, err := util.ReadFile(wt, ".gitmodules")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading .gitmodules: %w", err)
+	}
+
+	var modules gitconfig.Modules
+	if err := modules.Unmarshal(data); err != nil {
+		return fmt.Errorf("parsing .gitmodules: %w", err)
+	}
+
+	for name, sub := range modules.Submodules {
+		entry, err := tree.FindEntry(sub.Path)
+		if err != nil {
+			return fmt.Errorf("resolving submodule %q (path %q) in tree: %w", name, sub.Path, err)
+		}
+
+		subFS, err := wt.Chroot(sub.Path)
+		if err != nil {
+			return fmt.Errorf("submodule %q: %w", sub.Path, err)
+		}
+
+		subStorer, err := newSubmoduleStorer(subFS)
+		if err != nil {
+			return fmt.Errorf("submodule %q: %w", sub.Path, err)
+		}
+
+		subURL := resolveSubmoduleURL(parentRepoURL, sub.URL)
+
+		if err := Fetch(ctx, subURL, entry.Hash.String(), auth, subStorer, subFS, true, newSubmoduleStorer); err != nil {
+			return fmt.Errorf("submodule %q: %w", sub.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// scpLikeURLRegExp matches scp-style SSH remotes such as
+// "git@github.com:org/repo.git", as opposed to a proper "scheme://" URL,
+// which net/url doesn't parse into a scheme/host at all (the "@" isn't a
+// valid scheme character, so url.Parse rejects it for having a colon in
+// its first path segment).
+var scpLikeURLRegExp = regexp.MustCompile(`^(?:([^@\s]+)@)?([^:\s]+):(.+)$`)
+
+// splitSCPLike splits a scp-like SSH remote into its "user@host:" prefix and
+// path portion, e.g. "git@github.com:org/repo.git" -> ("git@github.com:",
+// "org/repo.git", true). It returns ok=false for proper "scheme://" URLs.
+func splitSCPLike(repoURL string) (prefix, subPath string, ok bool) {
+	if strings.Contains(repoURL, "://") {
+		return "", "", false
+	}
+
+	m := scpLikeURLRegExp.FindStringSubmatch(repoURL)
+	if m == nil {
+		return "", "", false
+	}
+
+	prefix = m[2] + ":"
+	if m[1] != "" {
+		prefix = m[1] + "@" + prefix
+	}
+
+	return prefix, m[3], true
+}
+
+// resolveSubmoduleURL resolves a (possibly relative) submodule URL against
+// the parent repository's URL, per gitmodules(5). Relative URLs against a
+// scp-like SSH parent (e.g. "git@github.com:org/repo.git") are resolved by
+// hand, since net/url can't parse that syntax into a scheme/host to
+// correctly rewrite against.
+func resolveSubmoduleURL(parentRepoURL, subURL string) string {
+	if !strings.HasPrefix(subURL, "./") && !strings.HasPrefix(subURL, "../") {
+		return subURL
+	}
+
+	if prefix, p, ok := splitSCPLike(parentRepoURL); ok {
+		return prefix + path.Join(p, subURL)
+	}
+
+	base, err := url.Parse(strings.TrimSuffix(parentRepoURL, "/") + "/")
+	if err != nil {
+		return subURL
+	}
+
+	ref, err := url.Parse(subURL)
+	if err != nil {
+		return subURL
+	}
+
+	return base.ResolveReference(ref).String()
+}
+
+// Fetch runs the shallow fetch + checkout (and, if opts.Recursive is set,
+// submodule recursion) described by opts into its configured storage.
+func (opts *Options) Fetch(ctx context.Context) error {
+	auth, err := opts.Auth()
+	if err != nil {
+		return err
+	}
+
+	return Fetch(ctx, opts.Repo, opts.SHA, auth, opts.storage, opts.worktree, opts.Recursive, opts.newSubmoduleStorer)
+}
+
+// newSubmoduleStorer builds a submodule's storer the same way SetStorageMode
+// built opts.storage, so recursive submodule fetches inherit the parent's
+// storage mode instead of always landing in memory.
+func (opts *Options) newSubmoduleStorer(sub billy.Filesystem) (storage.Storer, error) {
+	if opts.storageMode == MemoryStorageMode {
+		return memory.NewStorage(), nil
+	}
+
+	dotGit, err := sub.Chroot(git.GitDirName)
+	if err != nil {
+		return nil, err
+	}
+
+	return filesystem.NewStorage(dotGit, cache.NewObjectLRUDefault()), nil
+}
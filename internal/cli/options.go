@@ -1,9 +1,11 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -12,6 +14,8 @@ import (
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
@@ -19,8 +23,13 @@ import (
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/filesystem"
 	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/kevinburke/ssh_config"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
+
+	"github.com/tomjaguarpaw/shallow-fetch-sha/internal/blobstore"
+	"github.com/tomjaguarpaw/shallow-fetch-sha/internal/credentials"
+	"github.com/tomjaguarpaw/shallow-fetch-sha/internal/lfs"
 )
 
 type StorageMode string
@@ -28,6 +37,32 @@ type StorageMode string
 const (
 	FileSystemStorageMode StorageMode = "fs"
 	MemoryStorageMode     StorageMode = "mem"
+	// ObjectStoreStorageMode persists loose/packed objects and refs to a
+	// remote object store (S3 or GCS), selected by the URL scheme of
+	// Options.Directory (e.g. "s3://bucket/prefix", "gs://bucket/prefix").
+	ObjectStoreStorageMode StorageMode = "objectstore"
+)
+
+// DetectStorageMode chooses a StorageMode from the scheme of directory,
+// falling back to FileSystemStorageMode for a plain path.
+func DetectStorageMode(directory string) StorageMode {
+	switch {
+	case strings.HasPrefix(directory, "s3://"), strings.HasPrefix(directory, "gs://"):
+		return ObjectStoreStorageMode
+	default:
+		return FileSystemStorageMode
+	}
+}
+
+// GitAuth selects which authentication mechanism Options.Auth should use.
+type GitAuth string
+
+const (
+	GitAuthSSH       GitAuth = "ssh"
+	GitAuthSSHAgent  GitAuth = "ssh_agent"
+	GitAuthBasic     GitAuth = "basic"
+	GitAuthToken     GitAuth = "token"
+	GitAuthAnonymous GitAuth = "anonymous"
 )
 
 var (
@@ -35,17 +70,24 @@ var (
 )
 
 type Options struct {
-	Repo         string
-	SHA          string
-	Directory    string
-	RemoveDotGit bool
-	BasicAuth    *BasicAuthOptions
-	SSHAuth      *SSHAuthOptions
-
-	storage  storage.Storer
-	worktree billy.Filesystem
+	Repo               string
+	SHA                string
+	Directory          string
+	RemoveDotGit       bool
+	LFS                bool
+	Recursive          bool
+	AuthMethod         GitAuth
+	BasicAuth          *BasicAuthOptions
+	SSHAuth            *SSHAuthOptions
+	CredentialProvider credentials.Provider
+
+	storage     storage.Storer
+	worktree    billy.Filesystem
+	storageMode StorageMode
 }
 
+// SSHAuthOptions configures key-based SSH authentication. PEMPath may be left
+// empty to fall back to the running SSH agent (see GitAuthSSHAgent).
 type SSHAuthOptions struct {
 	PEMPath    string
 	Passphrase string
@@ -57,44 +99,255 @@ type BasicAuthOptions struct {
 }
 
 func (opts *Options) Auth() (transport.AuthMethod, error) {
+	switch opts.authMethod() {
+	case GitAuthSSH, GitAuthSSHAgent:
+		return opts.sshAuth()
+	case GitAuthBasic, GitAuthToken:
+		return opts.basicAuth()
+	case GitAuthAnonymous:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%q is an invalid git auth method", opts.AuthMethod)
+	}
+}
+
+// authMethod returns the configured GitAuth, inferring one from the populated
+// *AuthOptions fields when the caller hasn't set it explicitly.
+func (opts *Options) authMethod() GitAuth {
+	if opts.AuthMethod != "" {
+		return opts.AuthMethod
+	}
+
 	if opts.SSHAuth != nil {
-		// default user to 'git'
-		user := "git"
-
-		// if different user specified in ssh url
-		pieces := strings.Split(opts.Repo, ":")
-		if len(pieces) == 2 {
-			if parsed, err := url.Parse(pieces[0]); err != nil {
-				parsedUser := parsed.User.Username()
-				if parsedUser != "" {
-					user = parsedUser
-				}
+		return GitAuthSSH
+	}
+
+	if opts.BasicAuth != nil {
+		return GitAuthBasic
+	}
+
+	return GitAuthAnonymous
+}
+
+// sshHost extracts the host (alias or otherwise) out of opts.Repo so it can
+// be looked up in ~/.ssh/config.
+func (opts *Options) sshHost() string {
+	return sshHostOf(opts.Repo)
+}
+
+// sshHostOf extracts the host (alias or otherwise) out of a repo URL,
+// handling both scp-like ("git@host:org/repo") and ssh:// URLs.
+func sshHostOf(repoURL string) string {
+	if parsed, err := url.Parse(repoURL); err == nil && parsed.Host != "" {
+		return parsed.Hostname()
+	}
+
+	host := repoURL
+	if pieces := strings.SplitN(repoURL, ":", 2); len(pieces) == 2 {
+		host = pieces[0]
+	}
+
+	if idx := strings.LastIndex(host, "@"); idx != -1 {
+		host = host[idx+1:]
+	}
+
+	return host
+}
+
+func (opts *Options) sshAuth() (transport.AuthMethod, error) {
+	// default user to 'git'
+	user := "git"
+
+	// if different user specified in ssh url
+	pieces := strings.Split(opts.Repo, ":")
+	if len(pieces) == 2 {
+		if parsed, err := url.Parse(pieces[0]); err == nil {
+			if parsedUser := parsed.User.Username(); parsedUser != "" {
+				user = parsedUser
 			}
 		}
+	}
+
+	alias := opts.sshHost()
+	if cfgUser := ssh_config.Get(alias, "User"); cfgUser != "" {
+		user = cfgUser
+	}
+
+	pemPath := ""
+	passphrase := ""
+	if opts.SSHAuth != nil {
+		pemPath = opts.SSHAuth.PEMPath
+		passphrase = opts.SSHAuth.Passphrase
+	}
 
-		return ssh.NewPublicKeysFromFile(user, opts.SSHAuth.PEMPath, opts.SSHAuth.Passphrase)
+	if pemPath == "" {
+		pemPath = expandHome(ssh_config.Get(alias, "IdentityFile"))
 	}
 
+	if opts.AuthMethod == GitAuthSSHAgent || pemPath == "" {
+		return ssh.NewSSHAgentAuth(user)
+	}
+
+	return ssh.NewPublicKeysFromFile(user, pemPath, passphrase)
+}
+
+// ResolvedRepo returns opts.Repo with any ~/.ssh/config "Host" alias it uses
+// rewritten to the real HostName, so go-git's own transport dials the actual
+// remote rather than the alias.
+func (opts *Options) ResolvedRepo() string {
+	return resolveRepoURL(opts.Repo)
+}
+
+// resolveRepoURL rewrites any ~/.ssh/config "Host" alias used in repoURL to
+// its real HostName, so go-git's transport dials the actual remote rather
+// than the alias. Callers still use the unresolved repoURL for ssh_config
+// User/IdentityFile lookups, since those are keyed by the alias itself.
+func resolveRepoURL(repoURL string) string {
+	alias := sshHostOf(repoURL)
+	hostName := ssh_config.Get(alias, "HostName")
+	if hostName == "" || hostName == alias {
+		return repoURL
+	}
+
+	return strings.Replace(repoURL, alias, hostName, 1)
+}
+
+func (opts *Options) basicAuth() (transport.AuthMethod, error) {
+	user, password, err := opts.basicCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	if user == "" {
+		// when using a token, username doesn't matter, but it can't be empty
+		user = "token"
+	}
+
+	return &http.BasicAuth{
+		Username: user,
+		Password: password,
+	}, nil
+}
+
+// basicCredentials resolves a username/password pair from opts.BasicAuth if
+// set, falling back to opts.CredentialProvider (netrc/env/vault) so that
+// plaintext --username/--password flags aren't the only option.
+func (opts *Options) basicCredentials() (string, string, error) {
 	if opts.BasicAuth != nil {
-		user := opts.BasicAuth.Username
-		if user == "" {
-			// when using a token, username doesn't matter, but it can't be empty
-			user = "token"
-		}
+		return opts.BasicAuth.Username, opts.BasicAuth.Password, nil
+	}
+
+	if opts.CredentialProvider != nil {
+		return opts.CredentialProvider.Credentials(context.Background(), opts.sshHost())
+	}
+
+	return "", "", errors.New("basic auth requested but no username/password configured")
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
 
-		return &http.BasicAuth{
-			Username: opts.BasicAuth.Username,
-			Password: opts.BasicAuth.Password,
-		}, nil
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
 	}
 
-	return nil, nil
+	return filepath.Join(home, path[2:])
 }
 
 func invalid(key, msg string) error {
 	return fmt.Errorf("%q is invalid: %s", key, msg)
 }
 
+// ResolveSHA rewrites opts.SHA to a full 40-hex commit SHA1 when it was
+// given as a short SHA (>=7 hex characters), a tag name, or a branch name.
+// It performs a "git ls-remote" against opts.Repo, so it must be called
+// before Validate, which otherwise rejects anything but a full SHA.
+// Full SHAs are left untouched and never hit the network.
+func (opts *Options) ResolveSHA(ctx context.Context) error {
+	if len(opts.SHA) == 40 && regHex.MatchString(opts.SHA) {
+		return nil
+	}
+
+	auth, err := opts.Auth()
+	if err != nil {
+		return fmt.Errorf("resolving sha: %w", err)
+	}
+
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{opts.ResolvedRepo()},
+	})
+
+	refs, err := remote.ListContext(ctx, &git.ListOptions{
+		Auth:          auth,
+		PeelingOption: git.AppendPeeled,
+	})
+	if err != nil {
+		return fmt.Errorf("ls-remote %s: %w", opts.Repo, err)
+	}
+
+	sha, err := resolveRef(opts.SHA, refs)
+	if err != nil {
+		return err
+	}
+
+	opts.SHA = sha
+
+	return nil
+}
+
+// resolveRef maps input to a full commit SHA among refs returned by
+// ls-remote: an exact tag or branch name (annotated tags are peeled to the
+// commit they point at), or an unambiguous short-SHA prefix of a ref tip.
+func resolveRef(input string, refs []*plumbing.Reference) (string, error) {
+	for _, name := range []string{
+		"refs/tags/" + input + "^{}", // peeled annotated tag, if any
+		"refs/tags/" + input,
+		"refs/heads/" + input,
+	} {
+		for _, ref := range refs {
+			if ref.Name().String() == name {
+				return ref.Hash().String(), nil
+			}
+		}
+	}
+
+	if len(input) >= 7 && len(input) < 40 && regHex.MatchString(input) {
+		var matches []string
+		lower := strings.ToLower(input)
+		for _, ref := range refs {
+			hash := ref.Hash().String()
+			if strings.HasPrefix(hash, lower) {
+				matches = appendUnique(matches, hash)
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			return "", fmt.Errorf("%q does not match any ref tip returned by ls-remote", input)
+		case 1:
+			return matches[0], nil
+		default:
+			return "", fmt.Errorf("%q is ambiguous: matches %s", input, strings.Join(matches, ", "))
+		}
+	}
+
+	return "", fmt.Errorf("%q is not a full sha, known tag/branch, or unambiguous short sha", input)
+}
+
+func appendUnique(matches []string, hash string) []string {
+	for _, m := range matches {
+		if m == hash {
+			return matches
+		}
+	}
+
+	return append(matches, hash)
+}
+
 func (opts *Options) Validate() error {
 	if opts.Repo == "" {
 		return invalid("repo", "it is required")
@@ -118,10 +371,14 @@ func (opts *Options) Validate() error {
 		}
 	}
 
-	if opts.SSHAuth != nil {
-		if opts.SSHAuth.PEMPath == "" {
-			return invalid("key-path", "required if ssh options set")
-		}
+	if opts.SSHAuth != nil && opts.SSHAuth.PEMPath == "" && opts.AuthMethod != GitAuthSSHAgent {
+		return invalid("key-path", "required unless auth is ssh_agent")
+	}
+
+	switch opts.AuthMethod {
+	case "", GitAuthSSH, GitAuthSSHAgent, GitAuthBasic, GitAuthToken, GitAuthAnonymous:
+	default:
+		return invalid("auth", fmt.Sprintf("must be one of %s, %s, %s, %s, %s", GitAuthSSH, GitAuthSSHAgent, GitAuthBasic, GitAuthToken, GitAuthAnonymous))
 	}
 
 	if opts.worktree == nil || opts.storage == nil {
@@ -147,6 +404,12 @@ func (opts *Options) BindFlags(flags *flag.FlagSet) error {
 	}
 	opts.Directory = dir
 
+	if dir != "" {
+		if err := opts.SetStorageMode(DetectStorageMode(dir)); err != nil {
+			return err
+		}
+	}
+
 	username, err := flags.GetString("username")
 	if err != nil {
 		return err
@@ -191,15 +454,105 @@ func (opts *Options) BindFlags(flags *flag.FlagSet) error {
 		opts.SSHAuth.Passphrase = keyPhrase
 	}
 
+	auth, err := flags.GetString("auth")
+	if err != nil {
+		return err
+	}
+	if auth != "" {
+		opts.AuthMethod = GitAuth(auth)
+	}
+
+	sshAgent, err := flags.GetBool("ssh-agent")
+	if err != nil {
+		return err
+	}
+	if sshAgent {
+		opts.AuthMethod = GitAuthSSHAgent
+		if opts.SSHAuth == nil {
+			opts.SSHAuth = &SSHAuthOptions{}
+		}
+	}
+
 	rmDotGit, err := flags.GetBool("rm-dotgit")
 	if err != nil {
 		return err
 	}
 	opts.RemoveDotGit = rmDotGit
 
+	lfs, err := flags.GetBool("lfs")
+	if err != nil {
+		return err
+	}
+	opts.LFS = lfs
+
+	recursive, err := flags.GetBool("recurse-submodules")
+	if err != nil {
+		return err
+	}
+	opts.Recursive = recursive
+
+	credentialSpec, err := flags.GetString("credentials")
+	if err != nil {
+		return err
+	}
+	if credentialSpec != "" {
+		provider, err := credentials.Parse(credentialSpec)
+		if err != nil {
+			return err
+		}
+		opts.CredentialProvider = provider
+
+		// A provider is only consulted for basic/token auth (see
+		// basicCredentials), so pick that auth method unless the caller
+		// already chose one explicitly via --auth, --ssh-agent, or
+		// --username/--password.
+		if opts.AuthMethod == "" && opts.BasicAuth == nil {
+			opts.AuthMethod = GitAuthToken
+		}
+	}
+
 	return nil
 }
 
+// SmudgeLFS replaces any Git LFS pointer files in the checked-out worktree
+// with their real blob contents, fetched from opts.Repo's LFS batch API. It
+// is a no-op unless opts.LFS is set, and should be called after the
+// worktree has been checked out.
+func (opts *Options) SmudgeLFS(ctx context.Context) error {
+	if !opts.LFS {
+		return nil
+	}
+
+	if opts.worktree == nil {
+		return errors.New("filesystem storage not initalized")
+	}
+
+	auth, err := opts.Auth()
+	if err != nil {
+		return fmt.Errorf("lfs auth: %w", err)
+	}
+
+	var basicAuth *http.BasicAuth
+	switch a := auth.(type) {
+	case nil:
+		// anonymous: the LFS client proceeds unauthenticated
+	case *http.BasicAuth:
+		basicAuth = a
+	default:
+		// SSH auth (key or agent) has no HTTP token to hand the LFS batch
+		// API, and silently dropping it would just surface as an opaque
+		// "unexpected status" from the LFS server. Fail clearly instead.
+		return fmt.Errorf("lfs: SSH auth (%T) is not supported for the LFS batch API; configure basic or token auth for --lfs", a)
+	}
+
+	client := &lfs.Client{
+		Endpoint: lfs.Endpoint(opts.Repo),
+		Auth:     basicAuth,
+	}
+
+	return lfs.Smudge(ctx, opts.worktree, client)
+}
+
 func (opts *Options) SetStorageMode(mode StorageMode) error {
 	if opts.Directory == "" {
 		return errors.New("must initalize directory before setting storage mode")
@@ -225,11 +578,31 @@ func (opts *Options) SetStorageMode(mode StorageMode) error {
 
 		opts.worktree = wt
 		opts.storage = filesystem.NewStorage(dotGit, cache.NewObjectLRUDefault())
+		opts.storageMode = mode
 
 		return nil
 	case MemoryStorageMode:
 		opts.worktree = memfs.New()
 		opts.storage = memory.NewStorage()
+		opts.storageMode = mode
+
+		return nil
+	case ObjectStoreStorageMode:
+		client, prefix, err := blobstore.NewClient(context.Background(), opts.Directory)
+		if err != nil {
+			return err
+		}
+
+		wt := blobstore.New(client, prefix)
+
+		dotGit, err := wt.Chroot(git.GitDirName)
+		if err != nil {
+			return err
+		}
+
+		opts.worktree = wt
+		opts.storage = filesystem.NewStorage(dotGit, cache.NewObjectLRUDefault())
+		opts.storageMode = mode
 
 		return nil
 	default:
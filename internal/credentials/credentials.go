@@ -0,0 +1,33 @@
+// Package credentials externalizes basic-auth style git credentials out of
+// plaintext CLI flags, resolving them instead from ~/.netrc, the
+// environment, or a HashiCorp Vault KV secret.
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Provider resolves a username/password pair for a git host.
+type Provider interface {
+	Credentials(ctx context.Context, host string) (username, password string, err error)
+}
+
+// Parse builds a Provider from a --credentials flag value: "netrc", "env",
+// or a "vault://<mount>/data/<path>?username=<field>&password=<field>" spec.
+// An empty spec returns a nil Provider.
+func Parse(spec string) (Provider, error) {
+	switch {
+	case spec == "":
+		return nil, nil
+	case spec == "netrc":
+		return NetrcProvider{}, nil
+	case spec == "env":
+		return EnvProvider{}, nil
+	case strings.HasPrefix(spec, "vault://"):
+		return NewVaultProvider(spec)
+	default:
+		return nil, fmt.Errorf("%q is not a recognised credential provider (want netrc, env, or vault://...)", spec)
+	}
+}
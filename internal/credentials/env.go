@@ -0,0 +1,27 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+// EnvProvider resolves credentials from SHALLOW_FETCH_USERNAME,
+// SHALLOW_FETCH_PASSWORD, and SHALLOW_FETCH_TOKEN. A token takes precedence
+// over username/password and is returned as a basic-auth pair with "token"
+// as the username, matching cli.GitAuthToken's convention.
+type EnvProvider struct{}
+
+func (EnvProvider) Credentials(_ context.Context, _ string) (string, string, error) {
+	if token := os.Getenv("SHALLOW_FETCH_TOKEN"); token != "" {
+		return "token", token, nil
+	}
+
+	username := os.Getenv("SHALLOW_FETCH_USERNAME")
+	password := os.Getenv("SHALLOW_FETCH_PASSWORD")
+	if username == "" || password == "" {
+		return "", "", errors.New("neither SHALLOW_FETCH_TOKEN nor SHALLOW_FETCH_USERNAME/SHALLOW_FETCH_PASSWORD are set")
+	}
+
+	return username, password, nil
+}
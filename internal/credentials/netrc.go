@@ -0,0 +1,89 @@
+package credentials
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// NetrcProvider resolves credentials from ~/.netrc (or $NETRC), matching
+// the "machine" entry against the requested host.
+type NetrcProvider struct {
+	// Path overrides the default ~/.netrc / $NETRC lookup; mainly useful in
+	// tests. Left empty in normal use.
+	Path string
+}
+
+func (p NetrcProvider) path() (string, error) {
+	if p.Path != "" {
+		return p.Path, nil
+	}
+
+	if env := os.Getenv("NETRC"); env != "" {
+		return env, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".netrc"), nil
+}
+
+func (p NetrcProvider) Credentials(_ context.Context, host string) (string, string, error) {
+	path, err := p.path()
+	if err != nil {
+		return "", "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("opening netrc: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanWords)
+
+	var machine, login, password string
+	matched := false
+
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			if !scanner.Scan() {
+				continue
+			}
+			machine = scanner.Text()
+			matched = machine == host
+			login, password = "", ""
+		case "login":
+			if !scanner.Scan() {
+				continue
+			}
+			if matched {
+				login = scanner.Text()
+			}
+		case "password":
+			if !scanner.Scan() {
+				continue
+			}
+			if matched {
+				password = scanner.Text()
+			}
+		}
+
+		if matched && login != "" && password != "" {
+			return login, password, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", "", fmt.Errorf("reading netrc: %w", err)
+	}
+
+	return "", "", fmt.Errorf("no netrc entry for host %q", host)
+}
@@ -0,0 +1,94 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// VaultProvider resolves credentials from a HashiCorp Vault KV v2 secret,
+// addressed as "vault://<mount>/data/<path>?username=<field>&password=<field>".
+// VAULT_ADDR and VAULT_TOKEN are read from the environment at request time.
+type VaultProvider struct {
+	Path          string
+	UsernameField string
+	PasswordField string
+}
+
+// NewVaultProvider parses a "vault://..." credential spec into a
+// VaultProvider.
+func NewVaultProvider(spec string) (*VaultProvider, error) {
+	parsed, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault credential spec: %w", err)
+	}
+
+	usernameField := parsed.Query().Get("username")
+	if usernameField == "" {
+		usernameField = "username"
+	}
+
+	passwordField := parsed.Query().Get("password")
+	if passwordField == "" {
+		passwordField = "password"
+	}
+
+	return &VaultProvider{
+		Path:          strings.TrimPrefix(parsed.Host+parsed.Path, "/"),
+		UsernameField: usernameField,
+		PasswordField: passwordField,
+	}, nil
+}
+
+type vaultKVv2Response struct {
+	Data struct {
+		Data map[string]string `json:"data"`
+	} `json:"data"`
+}
+
+func (p *VaultProvider) Credentials(ctx context.Context, _ string) (string, string, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", "", fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set to resolve vault:// credentials")
+	}
+
+	reqURL := strings.TrimSuffix(addr, "/") + "/v1/" + p.Path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("vault request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("vault request: unexpected status %s", resp.Status)
+	}
+
+	var decoded vaultKVv2Response
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", "", fmt.Errorf("decoding vault response: %w", err)
+	}
+
+	username, ok := decoded.Data.Data[p.UsernameField]
+	if !ok {
+		return "", "", fmt.Errorf("vault secret at %q missing field %q", p.Path, p.UsernameField)
+	}
+
+	password, ok := decoded.Data.Data[p.PasswordField]
+	if !ok {
+		return "", "", fmt.Errorf("vault secret at %q missing field %q", p.Path, p.PasswordField)
+	}
+
+	return username, password, nil
+}
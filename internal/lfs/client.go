@@ -0,0 +1,154 @@
+package lfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	gohttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const mediaType = "application/vnd.git-lfs+json"
+
+// Client talks to a remote's Git LFS batch API, resolved from the repo's
+// normal clone URL as described by the LFS spec (".git" -> "/info/lfs").
+type Client struct {
+	Endpoint string
+	Auth     *gohttp.BasicAuth
+	HTTPDoer interface {
+		Do(*http.Request) (*http.Response, error)
+	}
+}
+
+// Endpoint derives the "<repo>/info/lfs" batch endpoint from a normal git
+// remote URL.
+func Endpoint(repoURL string) string {
+	return strings.TrimSuffix(repoURL, ".git") + ".git/info/lfs"
+}
+
+type batchObject struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+type batchAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header"`
+}
+
+type batchResponseObject struct {
+	OID     string                 `json:"oid"`
+	Size    int64                  `json:"size"`
+	Actions map[string]batchAction `json:"actions"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type batchResponse struct {
+	Objects []batchResponseObject `json:"objects"`
+}
+
+func (c *Client) httpClient() interface {
+	Do(*http.Request) (*http.Response, error)
+} {
+	if c.HTTPDoer != nil {
+		return c.HTTPDoer
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", mediaType)
+	if c.Auth != nil {
+		req.SetBasicAuth(c.Auth.Username, c.Auth.Password)
+	}
+
+	return req, nil
+}
+
+// Batch asks the LFS server how to download each pointer, via the LFS
+// "download" batch operation.
+func (c *Client) Batch(ctx context.Context, pointers []*Pointer) (map[string]batchAction, error) {
+	objects := make([]batchObject, len(pointers))
+	for i, p := range pointers {
+		objects[i] = batchObject{OID: p.OID, Size: p.Size}
+	}
+
+	payload, err := json.Marshal(struct {
+		Operation string        `json:"operation"`
+		Transfers []string      `json:"transfers"`
+		Objects   []batchObject `json:"objects"`
+	}{
+		Operation: "download",
+		Transfers: []string{"basic"},
+		Objects:   objects,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, c.Endpoint+"/objects/batch", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mediaType)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("lfs batch request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lfs batch request: unexpected status %s", resp.Status)
+	}
+
+	var decoded batchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("lfs batch response: %w", err)
+	}
+
+	actions := make(map[string]batchAction, len(decoded.Objects))
+	for _, obj := range decoded.Objects {
+		if obj.Error != nil {
+			return nil, fmt.Errorf("lfs object %s: %s", obj.OID, obj.Error.Message)
+		}
+		actions[obj.OID] = obj.Actions["download"]
+	}
+
+	return actions, nil
+}
+
+// Download streams the blob content for a single batch "download" action.
+func (c *Client) Download(ctx context.Context, action batchAction) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, action.Href, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range action.Header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading lfs object: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("downloading lfs object: unexpected status %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
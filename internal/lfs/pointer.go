@@ -0,0 +1,56 @@
+// Package lfs implements a minimal Git LFS smudge client: it scans a
+// worktree for LFS pointer files, fetches the referenced blobs from the
+// remote's LFS batch API, and replaces the pointers with the real contents.
+package lfs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// pointerHeader is the first line of every v1 LFS pointer file.
+const pointerHeader = "version https://git-lfs.github.com/spec/v1"
+
+// maxPointerSize bounds how much of a file we read while sniffing for a
+// pointer; real pointer files are well under 200 bytes.
+const maxPointerSize = 1024
+
+// Pointer is the parsed content of an LFS pointer file.
+type Pointer struct {
+	OID  string // sha256 hex digest, without the "sha256:" prefix
+	Size int64
+}
+
+// ParsePointer reports whether data is an LFS pointer file and, if so,
+// returns its OID and size.
+func ParsePointer(data []byte) (*Pointer, bool) {
+	if len(data) == 0 || len(data) > maxPointerSize {
+		return nil, false
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != pointerHeader {
+		return nil, false
+	}
+
+	var p Pointer
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "oid sha256:"):
+			p.OID = strings.TrimPrefix(line, "oid sha256:")
+		case strings.HasPrefix(line, "size "):
+			size, err := strconv.ParseInt(strings.TrimPrefix(line, "size "), 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			p.Size = size
+		}
+	}
+
+	if p.OID == "" {
+		return nil, false
+	}
+
+	return &p, true
+}
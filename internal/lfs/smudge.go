@@ -0,0 +1,122 @@
+package lfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// pointerFile pairs a parsed pointer with the worktree path it was found at.
+type pointerFile struct {
+	path    string
+	pointer *Pointer
+}
+
+// Smudge walks fs for LFS pointer files and replaces each one in place with
+// the real blob content fetched through client. It works against any
+// billy.Filesystem, so it is agnostic to the underlying storage mode
+// (on-disk checkout or in-memory worktree).
+func Smudge(ctx context.Context, fs billy.Filesystem, client *Client) error {
+	pointers, err := findPointers(fs, "/")
+	if err != nil {
+		return fmt.Errorf("scanning worktree for lfs pointers: %w", err)
+	}
+
+	if len(pointers) == 0 {
+		return nil
+	}
+
+	justPointers := make([]*Pointer, len(pointers))
+	for i, pf := range pointers {
+		justPointers[i] = pf.pointer
+	}
+
+	actions, err := client.Batch(ctx, justPointers)
+	if err != nil {
+		return err
+	}
+
+	for _, pf := range pointers {
+		action, ok := actions[pf.pointer.OID]
+		if !ok || action.Href == "" {
+			return fmt.Errorf("no download action returned for %s (oid %s)", pf.path, pf.pointer.OID)
+		}
+
+		if err := replaceWithBlob(ctx, fs, client, pf.path, action); err != nil {
+			return fmt.Errorf("smudging %s: %w", pf.path, err)
+		}
+	}
+
+	return nil
+}
+
+func findPointers(fs billy.Filesystem, dir string) ([]pointerFile, error) {
+	entries, err := fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pointers []pointerFile
+	for _, entry := range entries {
+		full := path.Join(dir, entry.Name())
+
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				continue
+			}
+			sub, err := findPointers(fs, full)
+			if err != nil {
+				return nil, err
+			}
+			pointers = append(pointers, sub...)
+			continue
+		}
+
+		if entry.Size() > maxPointerSize {
+			continue
+		}
+
+		data, err := util.ReadFile(fs, full)
+		if err != nil {
+			return nil, err
+		}
+
+		if p, ok := ParsePointer(data); ok {
+			pointers = append(pointers, pointerFile{path: full, pointer: p})
+		}
+	}
+
+	return pointers, nil
+}
+
+func replaceWithBlob(ctx context.Context, fs billy.Filesystem, client *Client, filename string, action batchAction) error {
+	body, err := client.Download(ctx, action)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmp := filename + ".lfstmp"
+	f, err := fs.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := fs.Remove(filename); err != nil {
+		return err
+	}
+
+	return fs.Rename(tmp, filename)
+}